@@ -0,0 +1,153 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/gapid/core/git"
+	"github.com/google/gapid/core/log"
+)
+
+// maxBisectLog is the number of changelists searched for the -good SHA
+// before giving up. This bounds the single git log call runBisect makes to
+// build the candidate range.
+const maxBisectLog = 10000
+
+// bisectStep is a single measurement taken during the binary search,
+// recorded so the full history of the search can be printed alongside the
+// final result.
+type bisectStep struct {
+	SHA   string
+	Value float64
+}
+
+// runBisect performs a binary search over the commits between -good and
+// -bad, looking for the first changelist whose -metric crossed -threshold
+// times the value measured at -good. It reuses the same build/trace/
+// captureStats plumbing as the default reporting mode.
+func runBisect(ctx context.Context, g *git.Git) error {
+	if *good == "" || *bad == "" {
+		return fmt.Errorf("-bisect requires both -good and -bad SHAs")
+	}
+
+	cls, err := g.LogFrom(ctx, *bad, maxBisectLog)
+	if err != nil {
+		return err
+	}
+
+	goodIdx := -1
+	for i, cl := range cls {
+		if strings.HasPrefix(cl.SHA.String(), *good) {
+			goodIdx = i
+			break
+		}
+	}
+	if goodIdx < 0 {
+		return fmt.Errorf("-good SHA %v not found in the %v changelists before -bad %v", *good, maxBisectLog, *bad)
+	}
+
+	// chrono[0] is -good, chrono[len-1] is -bad.
+	chrono := make([]git.CL, goodIdx+1)
+	for i, cl := range cls[:goodIdx+1] {
+		chrono[goodIdx-i] = cl
+	}
+
+	steps := []bisectStep{}
+	valueAt := map[int]float64{}
+
+	measureAt := func(i int) (float64, error) {
+		cl := chrono[i]
+		sha := cl.SHA.String()[:6]
+		log.I(ctx, "Bisect: measuring %v: %v", sha, cl.Subject)
+		if err := g.Checkout(ctx, cl.SHA); err != nil {
+			return 0, err
+		}
+		r, err := measure(ctx, sha, i, cl.Subject)
+		if err != nil {
+			return 0, err
+		}
+		v, err := metricValue(r, *metric)
+		if err != nil {
+			return 0, err
+		}
+		steps = append(steps, bisectStep{SHA: sha, Value: v})
+		valueAt[i] = v
+		return v, nil
+	}
+
+	goodValue, err := measureAt(0)
+	if err != nil {
+		return fmt.Errorf("failed to measure -good %v: %v", *good, err)
+	}
+	badValue, err := measureAt(len(chrono) - 1)
+	if err != nil {
+		return fmt.Errorf("failed to measure -bad %v: %v", *bad, err)
+	}
+	if badValue < goodValue*(*threshold) {
+		return fmt.Errorf("%v at -bad %v (%v) does not exceed %v at -good %v (%v) by the %vx threshold",
+			*metric, *bad, badValue, *metric, *good, goodValue, *threshold)
+	}
+
+	lo, hi := 0, len(chrono)-1
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		v, err := measureAt(mid)
+		if err != nil {
+			// Treat an unmeasurable CL as bad so the search keeps narrowing.
+			hi = mid
+			continue
+		}
+		if v >= goodValue*(*threshold) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	printBisectSteps(steps)
+	if v, ok := valueAt[hi]; ok {
+		fmt.Printf("\nFirst offending changelist: %v (%v %v vs %v at -good)\n",
+			chrono[hi].SHA.String(), *metric, v, goodValue)
+	} else {
+		fmt.Printf("\nFirst offending changelist: %v (%v unmeasurable, vs %v at -good)\n",
+			chrono[hi].SHA.String(), *metric, goodValue)
+	}
+	return nil
+}
+
+// metricValue extracts the mean of a named metric from stats (e.g.
+// "IncrementalBuildTime" or "FileSizes.GAPIS" — see collector_*.go for the
+// names each collector reports).
+func metricValue(r stats, name string) (float64, error) {
+	s, ok := r.Metrics[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown metric %q", name)
+	}
+	return s.Mean, nil
+}
+
+func printBisectSteps(steps []bisectStep) {
+	w := tabwriter.NewWriter(os.Stdout, 1, 4, 0, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintf(w, "sha\t | %v\n", *metric)
+	for _, s := range steps {
+		fmt.Fprintf(w, "%v\t | %v\n", s.SHA, s.Value)
+	}
+}
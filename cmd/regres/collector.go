@@ -0,0 +1,71 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Collector measures some aspect of a changelist and reports it as a set of
+// named numeric metrics. buildDir is the bazel-bin/pkg output directory of
+// the build that -collectors=buildtime (always run first) just produced.
+type Collector interface {
+	Name() string
+	Collect(ctx context.Context, sha, buildDir string) (map[string]float64, error)
+}
+
+// collectorRegistry holds every Collector known to the tool, keyed by Name.
+// Collectors register themselves from an init() in their own file.
+var collectorRegistry = map[string]Collector{}
+
+func registerCollector(c Collector) {
+	collectorRegistry[c.Name()] = c
+}
+
+// selectedCollectors resolves -collectors into the Collectors it names.
+func selectedCollectors() ([]Collector, error) {
+	cs := []Collector{}
+	for _, name := range strings.Split(*collectors, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		c, ok := collectorRegistry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q (available: %v)", name, collectorNames())
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
+
+func collectorNames() []string {
+	names := make([]string, 0, len(collectorRegistry))
+	for name := range collectorRegistry {
+		names = append(names, name)
+	}
+	return names
+}
+
+func hasCollector(cs []Collector, name string) bool {
+	for _, c := range cs {
+		if c.Name() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/gapid/core/os/shell"
+)
+
+func init() {
+	registerCollector(bazelAqueryCollector{})
+}
+
+// bazelAqueryCollector parses 'bazel aquery' output for the pkg target to
+// report the size of its action graph and the length of its critical path.
+type bazelAqueryCollector struct{}
+
+func (bazelAqueryCollector) Name() string { return "bazel" }
+
+var actionRE = regexp.MustCompile(`^action '`)
+var criticalPathRE = regexp.MustCompile(`Critical Path:\s*([0-9.]+)s`)
+
+func (bazelAqueryCollector) Collect(ctx context.Context, sha, buildDir string) (map[string]float64, error) {
+	cmd := shell.Cmd{
+		Name:      "bazel",
+		Args:      []string{"aquery", "pkg"},
+		Verbosity: *verbose,
+		Dir:       *root,
+	}
+	stdout, err := cmd.Call(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := 0
+	metrics := map[string]float64{}
+	for _, line := range strings.Split(stdout, "\n") {
+		if actionRE.MatchString(line) {
+			actions++
+		}
+		if m := criticalPathRE.FindStringSubmatch(line); m != nil {
+			if seconds, err := strconv.ParseFloat(m[1], 64); err == nil {
+				metrics["Bazel.CriticalPathSeconds"] = seconds
+			}
+		}
+	}
+	metrics["Bazel.ActionGraphSize"] = float64(actions)
+	return metrics, nil
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/gapid/core/os/shell"
+)
+
+func init() {
+	registerCollector(binarySectionCollector{})
+}
+
+// binarySectionCollector runs 'size -A' on the produced gapis and gapir
+// binaries to break their sizes out by section (.text, .rodata, .data).
+type binarySectionCollector struct{}
+
+func (binarySectionCollector) Name() string { return "binsize" }
+
+var sectionRE = regexp.MustCompile(`^(\.\w+)\s+([0-9]+)`)
+
+func (binarySectionCollector) Collect(ctx context.Context, sha, buildDir string) (map[string]float64, error) {
+	metrics := map[string]float64{}
+	for _, bin := range []string{"gapis", "gapir"} {
+		path := filepath.Join(buildDir, exeExt(bin))
+		sections, err := binarySections(ctx, path)
+		if err != nil {
+			continue
+		}
+		for section, size := range sections {
+			metrics["BinSize."+bin+"."+section] = size
+		}
+	}
+	return metrics, nil
+}
+
+// binarySections runs 'size -A path' and returns the sizes in bytes of its
+// .text, .rodata and .data sections, keyed by section name with the leading
+// '.' stripped. Other sections that 'size' reports are ignored.
+func binarySections(ctx context.Context, path string) (map[string]float64, error) {
+	cmd := shell.Cmd{
+		Name:      "size",
+		Args:      []string{"-A", path},
+		Verbosity: *verbose,
+	}
+	stdout, err := cmd.Call(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := map[string]float64{}
+	for _, line := range strings.Split(stdout, "\n") {
+		m := sectionRE.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		name := strings.TrimPrefix(m[1], ".")
+		switch name {
+		case "text", "rodata", "data":
+			if size, err := strconv.ParseFloat(m[2], 64); err == nil {
+				sections[name] = size
+			}
+		}
+	}
+	return sections, nil
+}
@@ -0,0 +1,162 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/shell"
+)
+
+func init() {
+	registerCollector(pprofCollector{})
+}
+
+// pprofCollector traces -pkg with gapis CPU and heap profiling enabled, and
+// reports total allocated bytes, heap bytes in use at the moment the profile
+// was written, and the share of CPU time spent in the five hottest
+// functions. Like the other collectors it shells
+// out to existing tooling (here, 'go tool pprof') and regex-parses its text
+// reports, rather than pulling in a profile-parsing library.
+type pprofCollector struct{}
+
+func (pprofCollector) Name() string { return "pprof" }
+
+func (pprofCollector) Collect(ctx context.Context, sha, buildDir string) (map[string]float64, error) {
+	if *pkg == "" {
+		return nil, nil
+	}
+
+	cpuProfile := filepath.Join(os.TempDir(), "gapid-regres-cpu.prof")
+	memProfile := filepath.Join(os.TempDir(), "gapid-regres-mem.prof")
+	defer os.Remove(cpuProfile)
+	defer os.Remove(memProfile)
+
+	file, err := trace(ctx, "--gapis-args", fmt.Sprintf("-cpuprofile=%v -memprofile=%v", cpuProfile, memProfile))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't capture profiled trace: %v", err)
+	}
+	defer os.Remove(file)
+
+	metrics := map[string]float64{}
+
+	if alloc, err := pprofTotal(ctx, memProfile, "alloc_space"); err == nil {
+		metrics["Pprof.AllocBytes"] = alloc
+	} else {
+		log.W(ctx, "Couldn't read heap alloc total from '%v': %v", memProfile, err)
+	}
+	if inuse, err := pprofTotal(ctx, memProfile, "inuse_space"); err == nil {
+		metrics["Pprof.InUseBytes"] = inuse
+	} else {
+		log.W(ctx, "Couldn't read heap inuse total from '%v': %v", memProfile, err)
+	}
+
+	if top, err := pprofTopFunctions(ctx, cpuProfile, 5); err == nil {
+		for i, pct := range top {
+			metrics[fmt.Sprintf("Pprof.TopFunc%dPct", i+1)] = pct
+		}
+	} else {
+		log.W(ctx, "Couldn't read CPU top functions from '%v': %v", cpuProfile, err)
+	}
+
+	return metrics, nil
+}
+
+// pprofTotalRE matches the summary line 'go tool pprof -top' prints, e.g.
+// "Showing nodes accounting for 1.20GB, 95.00% of 1.26GB total".
+var pprofTotalRE = regexp.MustCompile(`of ([0-9.]+)([a-zA-Z]*) total`)
+
+// pprofTotal runs 'go tool pprof -top' over the profile at path for the
+// given sample index (e.g. "alloc_space", "inuse_space") and returns the
+// grand total it reports, converted to bytes.
+func pprofTotal(ctx context.Context, path, sampleIndex string) (float64, error) {
+	cmd := shell.Cmd{
+		Name:      "go",
+		Args:      []string{"tool", "pprof", "-top", "-unit=bytes", "-sample_index=" + sampleIndex, path},
+		Verbosity: *verbose,
+	}
+	stdout, err := cmd.Call(ctx)
+	if err != nil {
+		return 0, err
+	}
+	m := pprofTotalRE.FindStringSubmatch(stdout)
+	if m == nil {
+		return 0, fmt.Errorf("couldn't find a total in 'go tool pprof' output")
+	}
+	return parseByteSize(m[1], m[2])
+}
+
+// pprofFuncRE matches one data row of 'go tool pprof -top's table, e.g.
+// "   120ms  12.00%  12.00%    200ms  20.00%  runtime.mallocgc". The second
+// column (flat%) is the share of total time spent in that function alone.
+var pprofFuncRE = regexp.MustCompile(`^\s*\S+\s+([0-9.]+)%\s+[0-9.]+%\s+\S+\s+[0-9.]+%\s+\S`)
+
+// pprofTopFunctions runs 'go tool pprof -top -nodecount=n' over the CPU
+// profile at path and returns the flat-time percentage of its n hottest
+// functions, in descending order.
+func pprofTopFunctions(ctx context.Context, path string, n int) ([]float64, error) {
+	cmd := shell.Cmd{
+		Name:      "go",
+		Args:      []string{"tool", "pprof", "-top", fmt.Sprintf("-nodecount=%d", n), path},
+		Verbosity: *verbose,
+	}
+	stdout, err := cmd.Call(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pcts := []float64{}
+	for _, line := range strings.Split(stdout, "\n") {
+		m := pprofFuncRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		pct, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			continue
+		}
+		pcts = append(pcts, pct)
+		if len(pcts) == n {
+			break
+		}
+	}
+	return pcts, nil
+}
+
+// parseByteSize converts a pprof-style size (e.g. "1.26", "GB") to bytes.
+func parseByteSize(value, unit string) (float64, error) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch unit {
+	case "kB", "KB":
+		v *= 1 << 10
+	case "MB":
+		v *= 1 << 20
+	case "GB":
+		v *= 1 << 30
+	case "TB":
+		v *= 1 << 40
+	}
+	return v, nil
+}
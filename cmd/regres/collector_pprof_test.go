@@ -0,0 +1,60 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		unit  string
+		want  float64
+	}{
+		{"42", "", 42},
+		{"1", "kB", 1 << 10},
+		{"1.5", "MB", 1.5 * (1 << 20)},
+		{"2", "GB", 2 * (1 << 30)},
+		{"1", "TB", 1 << 40},
+	} {
+		got, err := parseByteSize(test.value, test.unit)
+		if err != nil {
+			t.Errorf("parseByteSize(%q, %q): %v", test.value, test.unit, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseByteSize(%q, %q) = %v, want %v", test.value, test.unit, got, test.want)
+		}
+	}
+}
+
+func TestPprofTotalRE(t *testing.T) {
+	line := "Showing nodes accounting for 1.20GB, 95.00% of 1.26GB total"
+	m := pprofTotalRE.FindStringSubmatch(line)
+	if m == nil || m[1] != "1.26" || m[2] != "GB" {
+		t.Fatalf("pprofTotalRE.FindStringSubmatch(%q) = %v, want [_, 1.26, GB]", line, m)
+	}
+}
+
+func TestPprofFuncRE(t *testing.T) {
+	line := "   120ms  12.00%  12.00%    200ms  20.00%  runtime.mallocgc"
+	m := pprofFuncRE.FindStringSubmatch(line)
+	if m == nil || m[1] != "12.00" {
+		t.Fatalf("pprofFuncRE.FindStringSubmatch(%q) = %v, want flat%% of 12.00", line, m)
+	}
+
+	if m := pprofFuncRE.FindStringSubmatch("      flat  flat%   sum%        cum   cum%"); m != nil {
+		t.Errorf("pprofFuncRE matched the table header: %v", m)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"runtime"
+	"time"
+)
+
+// record is a single -db append-only entry: one changelist's stats, stamped
+// with when it was measured and which machine did the measuring, so that
+// cross-host noise is visible when the history is later plotted.
+type record struct {
+	SHA       string
+	Timestamp time.Time
+	Host      string
+	Stats     stats
+}
+
+// hostFingerprint identifies the machine running the measurement: hostname,
+// core count, CPU model and OS. It is recorded alongside every -db entry,
+// and each 'regress worker' stamps its own onto stats.Host so cross-host
+// noise is visible in the results.
+func hostFingerprint() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%v/%v cores/%v/%v", host, runtime.NumCPU(), cpuModel(), runtime.GOOS)
+}
+
+// cpuModel returns a short CPU model string on platforms where it's easy to
+// come by (Linux's /proc/cpuinfo), falling back to the architecture name
+// everywhere else.
+func cpuModel() string {
+	data, err := ioutil.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return runtime.GOARCH
+	}
+	if m := cpuModelRE.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+	return runtime.GOARCH
+}
+
+var cpuModelRE = regexp.MustCompile(`(?m)^model name\s*:\s*(.+)$`)
+
+// appendRecord appends a single JSON-encoded record to the -db append-only
+// log at path, creating it if necessary.
+func appendRecord(path string, r record) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	return enc.Encode(r)
+}
+
+// loadRecords reads every entry from the -db append-only log at path. A
+// missing file is treated as an empty history rather than an error, so a
+// fresh dashboard or query has somewhere to start from.
+func loadRecords(path string) ([]record, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records := []record{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
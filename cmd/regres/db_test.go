@@ -0,0 +1,61 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadRecordsMissingFile(t *testing.T) {
+	records, err := loadRecords(filepath.Join(t.TempDir(), "does-not-exist.jsonl"))
+	if err != nil {
+		t.Fatalf("loadRecords on a missing file: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("loadRecords on a missing file = %v, want none", records)
+	}
+}
+
+func TestAppendAndLoadRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.jsonl")
+
+	want := []record{
+		{SHA: "abc123", Timestamp: time.Unix(1000, 0).UTC(), Host: "host-a",
+			Stats: stats{SHA: "abc123", Metrics: map[string]sample{"BuildTime": {Mean: 1.5}}}},
+		{SHA: "def456", Timestamp: time.Unix(2000, 0).UTC(), Host: "host-b",
+			Stats: stats{SHA: "def456", Metrics: map[string]sample{"BuildTime": {Mean: 2.5}}}},
+	}
+	for _, r := range want {
+		if err := appendRecord(path, r); err != nil {
+			t.Fatalf("appendRecord(%+v): %v", r, err)
+		}
+	}
+
+	got, err := loadRecords(path)
+	if err != nil {
+		t.Fatalf("loadRecords: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("loadRecords returned %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].SHA != want[i].SHA || !got[i].Timestamp.Equal(want[i].Timestamp) ||
+			got[i].Host != want[i].Host || got[i].Stats.Metrics["BuildTime"].Mean != want[i].Stats.Metrics["BuildTime"].Mean {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -20,14 +20,9 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"io/ioutil"
-	"math/rand"
 	"os"
 	"path/filepath"
-	"regexp"
 	"runtime"
-	"strconv"
-	"text/tabwriter"
 	"time"
 
 	"github.com/google/gapid/core/app"
@@ -37,41 +32,62 @@ import (
 )
 
 var (
-	root     = flag.String("root", "", "Path to the root GAPID source directory")
-	verbose  = flag.Bool("verbose", false, "Verbose logging")
-	incBuild = flag.Bool("inc", true, "Time incremental builds")
-	optimize = flag.Bool("optimize", false, "Build using '-c opt'")
-	pkg      = flag.String("pkg", "", "Partial name of a package name to capture")
-	output   = flag.String("out", "", "The results output file. Empty writes to stdout")
-	atSHA    = flag.String("at", "", "The SHA or branch of the first changelist to profile")
-	count    = flag.Int("count", 2, "The number of changelists to profile since HEAD")
+	root       = flag.String("root", "", "Path to the root GAPID source directory")
+	verbose    = flag.Bool("verbose", false, "Verbose logging")
+	optimize   = flag.Bool("optimize", false, "Build using '-c opt'")
+	pkg        = flag.String("pkg", "", "Partial name of a package name to capture")
+	output     = flag.String("out", "", "The results output file. Empty writes to stdout")
+	atSHA      = flag.String("at", "", "The SHA or branch of the first changelist to profile")
+	count      = flag.Int("count", 2, "The number of changelists to profile since HEAD")
+	collectors = flag.String("collectors", "buildtime,incbuild,filesize,capturestats",
+		"Comma separated list of collectors to run. Available: buildtime, incbuild, filesize, capturestats, pprof, bazel, binsize")
+
+	runs    = flag.Int("runs", 1, "The number of times to build/trace/measure each changelist")
+	compare = flag.String("compare", "", "SHA to use as the baseline for percentage-delta comparisons")
+	format  = flag.String("format", "table", "Output format: table, tsv or json")
+
+	db   = flag.String("db", "", "Append each changelist's stats as a JSON line to this file, for 'regress serve' to read")
+	addr = flag.String("addr", ":8080", "Address for 'regress serve' or 'regress worker' to listen on")
+
+	workers = flag.String("workers", "", "Comma separated host:port list of 'regress worker' endpoints to distribute measurements across")
+
+	bisect    = flag.Bool("bisect", false, "Binary search for the CL that regressed a metric")
+	good      = flag.String("good", "", "SHA of a changelist known to not exhibit the regression")
+	bad       = flag.String("bad", "", "SHA of a changelist known to exhibit the regression")
+	metric    = flag.String("metric", "IncrementalBuildTime", "The metric to bisect on, e.g. IncrementalBuildTime")
+	threshold = flag.Float64("threshold", 1.2, "The multiple of the 'good' value that counts as a regression")
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+			app.ShortHelp = "regress serve hosts a dashboard over a -db of historical regression stats."
+			app.Run(runServe)
+			return
+		case "worker":
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+			app.ShortHelp = "regress worker measures changelists on behalf of a -workers coordinator."
+			app.Run(runWorker)
+			return
+		}
+	}
+
 	app.ShortHelp = "Regress is a tool to perform performance measurments over a range of CLs."
 	app.Run(run)
 }
 
+// stats holds the -runs samples gathered for a single changelist. Metrics
+// are keyed by the name the collector that produced them gave it (e.g.
+// "IncrementalBuildTime", "FileSizes.GAPIS") rather than fixed struct
+// fields, so new collectors don't require changes here. Host identifies the
+// machine that produced the measurement; it's only populated when -workers
+// is used, since cross-host noise is otherwise not a concern.
 type stats struct {
-	SHA                  string
-	BuildTime            float64  // in seconds
-	IncrementalBuildTime float64  // in seconds
-	FileSizes            struct { // in bytes
-		LibGAPII                   int64
-		LibVkLayerVirtualSwapchain int64
-		GAPIDAarch64APK            int64
-		GAPIDArmeabi64APK          int64
-		GAPIDX86APK                int64
-		GAPID                      int64
-		GAPIR                      int64
-		GAPIS                      int64
-		GAPIT                      int64
-	}
-	CaptureStats struct {
-		Frames    int
-		DrawCalls int
-		Commands  int
-	}
+	SHA     string
+	Host    string
+	Metrics map[string]sample
 }
 
 func run(ctx context.Context) error {
@@ -102,166 +118,127 @@ func run(ctx context.Context) error {
 
 	defer g.CheckoutBranch(ctx, branch)
 
+	if *bisect {
+		return runBisect(ctx, g)
+	}
+
 	cls, err := g.LogFrom(ctx, *atSHA, *count)
 	if err != nil {
 		return err
 	}
 
-	rnd := rand.New(rand.NewSource(time.Now().Unix()))
-
-	res := []stats{}
-	for i := range cls {
-		i := len(cls) - 1 - i
-		cl := cls[i]
-		sha := cl.SHA.String()[:6]
-
-		r := stats{SHA: sha}
-
-		log.I(ctx, "HEAD~%.2d: Building at %v: %v", i, sha, cl.Subject)
-		if err := g.Checkout(ctx, cl.SHA); err != nil {
+	var res []stats
+	if *workers != "" {
+		res, err = measureDistributed(ctx, cls)
+		if err != nil {
 			return err
 		}
+	} else {
+		for i := range cls {
+			i := len(cls) - 1 - i
+			cl := cls[i]
+			sha := cl.SHA.String()[:6]
 
-		_, err := build(ctx)
-		if err != nil {
-			continue
-		}
+			log.I(ctx, "HEAD~%.2d: Measuring %v: %v", i, sha, cl.Subject)
+			if err := g.Checkout(ctx, cl.SHA); err != nil {
+				return err
+			}
 
-		// Gather file size build stats
-		pkgDir := filepath.Join(*root, "bazel-bin", "pkg")
-		for _, f := range []struct {
-			path string
-			size *int64
-		}{
-			{filepath.Join(pkgDir, "lib", dllExt("libgapii")), &r.FileSizes.LibGAPII},
-			{filepath.Join(pkgDir, "lib", dllExt("libVkLayer_VirtualSwapchain")), &r.FileSizes.LibVkLayerVirtualSwapchain},
-			{filepath.Join(pkgDir, "gapid-aarch64.apk"), &r.FileSizes.GAPIDAarch64APK},
-			{filepath.Join(pkgDir, "gapid-armeabi.apk"), &r.FileSizes.GAPIDArmeabi64APK},
-			{filepath.Join(pkgDir, "gapid-x86.apk"), &r.FileSizes.GAPIDX86APK},
-			{filepath.Join(pkgDir, exeExt("gapid")), &r.FileSizes.GAPID},
-			{filepath.Join(pkgDir, exeExt("gapir")), &r.FileSizes.GAPIR},
-			{filepath.Join(pkgDir, exeExt("gapis")), &r.FileSizes.GAPIS},
-			{filepath.Join(pkgDir, exeExt("gapit")), &r.FileSizes.GAPIT},
-		} {
-			fi, err := os.Stat(f.path)
+			r, err := measure(ctx, sha, i, cl.Subject)
 			if err != nil {
-				log.W(ctx, "Couldn't stat file '%v': %v", f.path, err)
 				continue
 			}
-			*f.size = fi.Size()
+
+			res = append(res, r)
 		}
+	}
 
-		// Gather capture stats
-		if *pkg != "" {
-			file, err := trace(ctx)
-			if err != nil {
-				log.W(ctx, "Couldn't capture trace: %v", err)
-				continue
+	for _, r := range res {
+		if *db != "" {
+			host := r.Host
+			if host == "" {
+				host = hostFingerprint()
 			}
-			defer os.Remove(file)
-			frames, draws, cmds, err := captureStats(ctx, file)
-			if err != nil {
-				continue
+			rec := record{SHA: r.SHA, Timestamp: time.Now(), Host: host, Stats: r}
+			if err := appendRecord(*db, rec); err != nil {
+				log.W(ctx, "Couldn't append to -db %v: %v", *db, err)
 			}
-			r.CaptureStats.Frames = frames
-			r.CaptureStats.DrawCalls = draws
-			r.CaptureStats.Commands = cmds
 		}
+	}
 
-		// Gather incremental build stats
-		if *incBuild {
-			if err := withTouchedGLES(ctx, rnd, func() error {
-				log.I(ctx, "HEAD~%.2d: Building incremental change at %v: %v", i, sha, cl.Subject)
-				if duration, err := build(ctx); err == nil {
-					r.IncrementalBuildTime = duration.Seconds()
-				}
-				return nil
-			}); err != nil {
-				continue
-			}
-		}
+	return writeResults(res)
+}
 
-		res = append(res, r)
+// measure runs the selected -collectors -runs times over the currently
+// checked-out changelist and folds each metric into a sample across those
+// runs. sha is the short SHA to stamp onto the result, and i and subject are
+// only used for progress logging.
+func measure(ctx context.Context, sha string, i int, subject string) (stats, error) {
+	cs, err := selectedCollectors()
+	if err != nil {
+		return stats{}, err
 	}
 
-	fmt.Printf("-----------------------\n")
+	n := *runs
+	if n < 1 {
+		n = 1
+	}
+	buildDir := filepath.Join(*root, "bazel-bin", "pkg")
 
-	w := tabwriter.NewWriter(os.Stdout, 1, 4, 0, ' ', 0)
-	defer w.Flush()
+	raw := map[string][]float64{}
+	for run := 0; run < n; run++ {
+		log.I(ctx, "HEAD~%.2d: [run %d/%d] Measuring %v: %v", i, run+1, n, sha, subject)
 
-	fmt.Fprint(w, "sha")
-	if *incBuild {
-		fmt.Fprint(w, "\t | incremental_build_time")
-	}
-	if *pkg != "" {
-		fmt.Fprint(w, "\t | commands")
-		fmt.Fprint(w, "\t | draws")
-		fmt.Fprint(w, "\t | frames")
-	}
-	fmt.Fprint(w, "\t | lib_gapii")
-	fmt.Fprint(w, "\t | lib_swapchain")
-	fmt.Fprint(w, "\t | aarch64.apk")
-	fmt.Fprint(w, "\t | armeabi64.apk")
-	fmt.Fprint(w, "\t | x86.apk")
-	fmt.Fprint(w, "\t | gapid")
-	fmt.Fprint(w, "\t | gapir")
-	fmt.Fprint(w, "\t | gapis")
-	fmt.Fprint(w, "\t | gapit\n")
-	for _, r := range res {
-		fmt.Fprintf(w, "%v,", r.SHA)
-		if *incBuild {
-			fmt.Fprintf(w, "\t   %v,", r.IncrementalBuildTime)
+		// The build always runs first, since almost every other collector
+		// inspects its output. buildTimeCollector itself performs this
+		// build and times it, but that time is only meaningful on the first
+		// run: bazel caches the result, so runs 2..n would just be sampling
+		// cache-hit noise. Keep it only when "buildtime" is selected and
+		// this is that first run.
+		bm, err := (buildTimeCollector{}).Collect(ctx, sha, buildDir)
+		if err != nil {
+			return stats{}, err
 		}
-		if *pkg != "" {
-			fmt.Fprintf(w, "\t   %v,", r.CaptureStats.Commands)
-			fmt.Fprintf(w, "\t   %v,", r.CaptureStats.DrawCalls)
-			fmt.Fprintf(w, "\t   %v,", r.CaptureStats.Frames)
+		if run == 0 && hasCollector(cs, "buildtime") {
+			addValues(raw, bm)
 		}
-		fmt.Fprintf(w, "\t   %v,", r.FileSizes.LibGAPII)
-		fmt.Fprintf(w, "\t   %v,", r.FileSizes.LibVkLayerVirtualSwapchain)
-		fmt.Fprintf(w, "\t   %v,", r.FileSizes.GAPIDAarch64APK)
-		fmt.Fprintf(w, "\t   %v,", r.FileSizes.GAPIDArmeabi64APK)
-		fmt.Fprintf(w, "\t   %v,", r.FileSizes.GAPIDX86APK)
-		fmt.Fprintf(w, "\t   %v,", r.FileSizes.GAPID)
-		fmt.Fprintf(w, "\t   %v,", r.FileSizes.GAPIR)
-		fmt.Fprintf(w, "\t   %v,", r.FileSizes.GAPIS)
-		fmt.Fprintf(w, "\t   %v", r.FileSizes.GAPIT)
-		fmt.Fprintf(w, "\n")
-	}
-	return nil
-}
 
-func withTouchedGLES(ctx context.Context, r *rand.Rand, f func() error) error {
-	glesAPIPath := filepath.Join(*root, "gapis", "api", "gles", "gles.api")
-	fi, err := os.Stat(glesAPIPath)
-	if err != nil {
-		return err
+		// incbuild leaves its injected GLES command's build artifacts behind
+		// in buildDir (withTouchedGLES only restores gles.api's source), so
+		// it has to run after every other collector that inspects buildDir
+		// (filesize, capturestats), regardless of -collectors order.
+		for _, c := range cs {
+			if c.Name() == "buildtime" || c.Name() == "incbuild" {
+				continue
+			}
+			m, err := c.Collect(ctx, sha, buildDir)
+			if err != nil {
+				log.W(ctx, "Collector %v failed: %v", c.Name(), err)
+				continue
+			}
+			addValues(raw, m)
+		}
+		if hasCollector(cs, "incbuild") {
+			m, err := (incrementalBuildCollector{}).Collect(ctx, sha, buildDir)
+			if err != nil {
+				log.W(ctx, "Collector incbuild failed: %v", err)
+			} else {
+				addValues(raw, m)
+			}
+		}
 	}
-	glesAPI, err := ioutil.ReadFile(glesAPIPath)
-	if err != nil {
-		return err
+
+	r := stats{SHA: sha, Metrics: map[string]sample{}}
+	for name, values := range raw {
+		r.Metrics[name] = newSample(values)
 	}
-	modGlesAPI := []byte(fmt.Sprintf("%v\ncmd void fake_cmd_%d() {}\n", string(glesAPI), r.Int()))
-	ioutil.WriteFile(glesAPIPath, modGlesAPI, fi.Mode().Perm())
-	defer ioutil.WriteFile(glesAPIPath, glesAPI, fi.Mode().Perm())
-	return f()
+	return r, nil
 }
 
-func build(ctx context.Context) (time.Duration, error) {
-	args := []string{"build"}
-	if *optimize {
-		args = append(args, "-c", "opt")
-	}
-	args = append(args, "pkg")
-	cmd := shell.Cmd{
-		Name:      "bazel",
-		Args:      args,
-		Verbosity: *verbose,
-		Dir:       *root,
+func addValues(raw map[string][]float64, m map[string]float64) {
+	for name, v := range m {
+		raw[name] = append(raw[name], v)
 	}
-	start := time.Now()
-	_, err := cmd.Call(ctx)
-	return time.Since(start), err
 }
 
 func dllExt(n string) string {
@@ -286,48 +263,34 @@ func exeExt(n string) string {
 
 func gapitPath() string { return filepath.Join(*root, "bazel-bin", "pkg", exeExt("gapit")) }
 
-func trace(ctx context.Context) (string, error) {
-	file := filepath.Join(os.TempDir(), "gapid-regres.gfxtrace")
+func build(ctx context.Context) (time.Duration, error) {
+	args := []string{"build"}
+	if *optimize {
+		args = append(args, "-c", "opt")
+	}
+	args = append(args, "pkg")
 	cmd := shell.Cmd{
-		Name:      gapitPath(),
-		Args:      []string{"--log-style", "raw", "trace", "--for", "60s", "--out", file, *pkg},
+		Name:      "bazel",
+		Args:      args,
 		Verbosity: *verbose,
+		Dir:       *root,
 	}
+	start := time.Now()
 	_, err := cmd.Call(ctx)
-	if err != nil {
-		os.Remove(file)
-		return "", err
-	}
-	return file, err
+	return time.Since(start), err
 }
 
-func captureStats(ctx context.Context, file string) (numFrames, numDraws, numCmds int, err error) {
+func trace(ctx context.Context, args ...string) (string, error) {
+	file := filepath.Join(os.TempDir(), "gapid-regres.gfxtrace")
 	cmd := shell.Cmd{
 		Name:      gapitPath(),
-		Args:      []string{"--log-style", "raw", "--log-level", "error", "stats", file},
+		Args:      append(append([]string{"--log-style", "raw", "trace", "--for", "60s", "--out", file}, args...), *pkg),
 		Verbosity: *verbose,
 	}
-	stdout, err := cmd.Call(ctx)
+	_, err := cmd.Call(ctx)
 	if err != nil {
-		return 0, 0, 0, nil
-	}
-	re := regexp.MustCompile(`([a-zA-Z]+):\s+([0-9]+)`)
-	for _, matches := range re.FindAllStringSubmatch(stdout, -1) {
-		if len(matches) != 3 {
-			continue
-		}
-		n, err := strconv.Atoi(matches[2])
-		if err != nil {
-			continue
-		}
-		switch matches[1] {
-		case "Frames":
-			numFrames = n
-		case "Draws":
-			numDraws = n
-		case "Commands":
-			numCmds = n
-		}
+		os.Remove(file)
+		return "", err
 	}
-	return
-}
\ No newline at end of file
+	return file, err
+}
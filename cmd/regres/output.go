@@ -0,0 +1,180 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// metricOrder lists well-known metrics in the order they should appear in
+// table/tsv output. Metrics reported by collectors not listed here (e.g. a
+// newly added one) are appended afterwards, sorted alphabetically.
+var metricOrder = []string{
+	"IncrementalBuildTime",
+	"CaptureStats.Commands",
+	"CaptureStats.DrawCalls",
+	"CaptureStats.Frames",
+	"FileSizes.LibGAPII",
+	"FileSizes.LibVkLayerVirtualSwapchain",
+	"FileSizes.GAPIDAarch64APK",
+	"FileSizes.GAPIDArmeabi64APK",
+	"FileSizes.GAPIDX86APK",
+	"FileSizes.GAPID",
+	"FileSizes.GAPIR",
+	"FileSizes.GAPIS",
+	"FileSizes.GAPIT",
+	"BuildTime",
+}
+
+// columns returns, in display order, every metric name present in res.
+func columns(res []stats) []string {
+	present := map[string]bool{}
+	for _, r := range res {
+		for name := range r.Metrics {
+			present[name] = true
+		}
+	}
+
+	cols := []string{}
+	for _, name := range metricOrder {
+		if present[name] {
+			cols = append(cols, name)
+			delete(present, name)
+		}
+	}
+
+	extra := make([]string, 0, len(present))
+	for name := range present {
+		extra = append(extra, name)
+	}
+	sort.Strings(extra)
+	return append(cols, extra...)
+}
+
+// writeResults renders res in the requested -format to -out (or stdout).
+func writeResults(res []stats) error {
+	w := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "json":
+		return writeJSON(w, res)
+	case "tsv":
+		return writeDelimited(w, res, "\t")
+	case "table":
+		return writeTable(w, res)
+	default:
+		return fmt.Errorf("unknown -format %q, want table, tsv or json", *format)
+	}
+}
+
+func writeJSON(w io.Writer, res []stats) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+// baseline finds the row matching -compare, or the zero value if -compare is
+// unset. r.SHA is truncated to 6 characters (see measure()), while -compare
+// is typically given as a full SHA like -at/-good/-bad are, so the match has
+// to work in either direction. It's an error for -compare to be set but
+// match nothing, rather than silently dropping every delta/sig column.
+func baseline(res []stats) (stats, bool, error) {
+	if *compare == "" {
+		return stats{}, false, nil
+	}
+	for _, r := range res {
+		if strings.HasPrefix(r.SHA, *compare) || strings.HasPrefix(*compare, r.SHA) {
+			return r, true, nil
+		}
+	}
+	return stats{}, false, fmt.Errorf("-compare %v matched no measured changelist", *compare)
+}
+
+func writeDelimited(w io.Writer, res []stats, sep string) error {
+	cols := columns(res)
+	base, hasBase, err := baseline(res)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(w, "sha")
+	for _, name := range cols {
+		fmt.Fprintf(w, "%s%v.mean%s%v.stddev%s%v.median%s%v.p95", sep, name, sep, name, sep, name, sep, name)
+		if hasBase {
+			fmt.Fprintf(w, "%s%v.delta%s%v.sig", sep, name, sep, name)
+		}
+	}
+	fmt.Fprintln(w)
+
+	for _, r := range res {
+		fmt.Fprint(w, r.SHA)
+		for _, name := range cols {
+			s := r.Metrics[name]
+			fmt.Fprintf(w, "%s%v%s%v%s%v%s%v", sep, s.Mean, sep, s.StdDev, sep, s.Median, sep, s.P95)
+			if hasBase {
+				pct, marker := delta(base.Metrics[name], s)
+				fmt.Fprintf(w, "%s%.2f%%%s%s", sep, pct, sep, marker)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func writeTable(w io.Writer, res []stats) error {
+	cols := columns(res)
+	base, hasBase, err := baseline(res)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 1, 4, 0, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprint(tw, "sha")
+	for _, name := range cols {
+		fmt.Fprintf(tw, "\t | %v", name)
+	}
+	fmt.Fprintln(tw)
+
+	for _, r := range res {
+		fmt.Fprintf(tw, "%v", r.SHA)
+		for _, name := range cols {
+			s := r.Metrics[name]
+			cell := fmt.Sprintf("%v ± %v", s.Mean, s.StdDev)
+			if hasBase {
+				pct, marker := delta(base.Metrics[name], s)
+				cell = fmt.Sprintf("%v (%+.1f%%%v)", cell, pct, marker)
+			}
+			fmt.Fprintf(tw, "\t | %v", cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	return nil
+}
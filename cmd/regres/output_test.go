@@ -0,0 +1,64 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestColumns(t *testing.T) {
+	res := []stats{
+		{Metrics: map[string]sample{"BuildTime": {}, "Zebra": {}}},
+		{Metrics: map[string]sample{"FileSizes.GAPIS": {}, "Apple": {}}},
+	}
+	got := columns(res)
+	want := []string{"FileSizes.GAPIS", "BuildTime", "Apple", "Zebra"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("columns() = %v, want %v", got, want)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	res := []stats{
+		{SHA: "abc123", Metrics: map[string]sample{}},
+		{SHA: "def456", Metrics: map[string]sample{}},
+	}
+
+	old := *compare
+	defer func() { *compare = old }()
+
+	*compare = ""
+	if _, ok, err := baseline(res); ok || err != nil {
+		t.Errorf("empty -compare: got (ok=%v, err=%v), want (false, nil)", ok, err)
+	}
+
+	*compare = "def456789012345678901234567890123456789a" // a full 40-char SHA
+	r, ok, err := baseline(res)
+	if err != nil || !ok || r.SHA != "def456" {
+		t.Errorf("full-SHA -compare: got (r=%+v, ok=%v, err=%v), want def456 row", r, ok, err)
+	}
+
+	*compare = "abc"
+	r, ok, err = baseline(res)
+	if err != nil || !ok || r.SHA != "abc123" {
+		t.Errorf("short-prefix -compare: got (r=%+v, ok=%v, err=%v), want abc123 row", r, ok, err)
+	}
+
+	*compare = "nonexistent"
+	if _, ok, err := baseline(res); ok || err == nil {
+		t.Errorf("unmatched -compare: got (ok=%v, err=%v), want an error", ok, err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// sample holds the N measurements taken for a single metric on a single
+// changelist (see -runs), along with the summary statistics computed from
+// them.
+type sample struct {
+	Values []float64
+	Mean   float64
+	StdDev float64
+	Median float64
+	P95    float64
+}
+
+// newSample computes the summary statistics for a set of raw measurements.
+func newSample(values []float64) sample {
+	s := sample{Values: values}
+	n := len(values)
+	if n == 0 {
+		return s
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	s.Mean = sum / float64(n)
+
+	if n > 1 {
+		sumSq := 0.0
+		for _, v := range values {
+			d := v - s.Mean
+			sumSq += d * d
+		}
+		s.StdDev = math.Sqrt(sumSq / float64(n-1))
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	s.Median = percentile(sorted, 0.5)
+	s.P95 = percentile(sorted, 0.95)
+	return s
+}
+
+// percentile returns the p-th percentile (0..1) of an already sorted slice.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	idx := p * float64(len(sorted)-1)
+	lo := int(math.Floor(idx))
+	hi := int(math.Ceil(idx))
+	if lo == hi {
+		return sorted[lo]
+	}
+	frac := idx - float64(lo)
+	return sorted[lo]*(1-frac) + sorted[hi]*frac
+}
+
+// delta compares candidate against baseline, returning the percentage change
+// and a benchstat-style significance marker derived from a Welch's t-test
+// over the two samples ("~" inconclusive, "*" significant at two-sided
+// p≈0.05, "**" significant at two-sided p≈0.01). This is a light-weight
+// approximation of benchstat's Mann-Whitney U test, not a substitute for it.
+func delta(baseline, candidate sample) (pct float64, marker string) {
+	if baseline.Mean == 0 {
+		return 0, "~"
+	}
+	pct = (candidate.Mean - baseline.Mean) / baseline.Mean * 100
+
+	na, nb := len(baseline.Values), len(candidate.Values)
+	if na < 2 || nb < 2 {
+		return pct, "~"
+	}
+
+	varA := baseline.StdDev * baseline.StdDev
+	varB := candidate.StdDev * candidate.StdDev
+	se := math.Sqrt(varA/float64(na) + varB/float64(nb))
+	if se == 0 {
+		return pct, "~"
+	}
+	t := math.Abs(candidate.Mean-baseline.Mean) / se
+
+	switch {
+	case t >= 2.58:
+		marker = "**"
+	case t >= 1.96:
+		marker = "*"
+	default:
+		marker = "~"
+	}
+	return pct, marker
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewSample(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		values []float64
+		mean   float64
+		stddev float64
+		median float64
+		p95    float64
+	}{
+		{"empty", nil, 0, 0, 0, 0},
+		{"single", []float64{5}, 5, 0, 5, 5},
+		{"pair", []float64{1, 3}, 2, math.Sqrt2, 2, 2.9},
+		{"five", []float64{1, 2, 3, 4, 5}, 3, math.Sqrt(2.5), 3, 4.8},
+	} {
+		s := newSample(test.values)
+		if !closeEnough(s.Mean, test.mean) {
+			t.Errorf("%v: Mean = %v, want %v", test.name, s.Mean, test.mean)
+		}
+		if !closeEnough(s.StdDev, test.stddev) {
+			t.Errorf("%v: StdDev = %v, want %v", test.name, s.StdDev, test.stddev)
+		}
+		if !closeEnough(s.Median, test.median) {
+			t.Errorf("%v: Median = %v, want %v", test.name, s.Median, test.median)
+		}
+		if !closeEnough(s.P95, test.p95) {
+			t.Errorf("%v: P95 = %v, want %v", test.name, s.P95, test.p95)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+	for _, test := range []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1},
+		{0.5, 3},
+		{1, 5},
+		{0.25, 2},
+	} {
+		if got := percentile(sorted, test.p); !closeEnough(got, test.want) {
+			t.Errorf("percentile(%v, %v) = %v, want %v", sorted, test.p, got, test.want)
+		}
+	}
+}
+
+func TestDelta(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		baseline   sample
+		candidate  sample
+		wantPct    float64
+		wantMarker string
+	}{
+		{
+			name:       "zero baseline mean",
+			baseline:   sample{Mean: 0},
+			candidate:  sample{Mean: 5},
+			wantPct:    0,
+			wantMarker: "~",
+		},
+		{
+			name:       "too few samples",
+			baseline:   sample{Values: []float64{1}, Mean: 1},
+			candidate:  sample{Values: []float64{2}, Mean: 2},
+			wantPct:    100,
+			wantMarker: "~",
+		},
+		{
+			name:       "indistinguishable from noise",
+			baseline:   sample{Values: []float64{1, 1}, Mean: 10, StdDev: 5},
+			candidate:  sample{Values: []float64{1, 1}, Mean: 11, StdDev: 5},
+			wantPct:    10,
+			wantMarker: "~",
+		},
+		{
+			name:       "highly significant",
+			baseline:   sample{Values: []float64{1, 1}, Mean: 10, StdDev: 0.1},
+			candidate:  sample{Values: []float64{1, 1}, Mean: 20, StdDev: 0.1},
+			wantPct:    100,
+			wantMarker: "**",
+		},
+	} {
+		pct, marker := delta(test.baseline, test.candidate)
+		if !closeEnough(pct, test.wantPct) {
+			t.Errorf("%v: pct = %v, want %v", test.name, pct, test.wantPct)
+		}
+		if marker != test.wantMarker {
+			t.Errorf("%v: marker = %v, want %v", test.name, marker, test.wantMarker)
+		}
+	}
+}
+
+func closeEnough(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -0,0 +1,173 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/gapid/core/log"
+)
+
+// dashboardMetrics lists the metrics plotted on the "regress serve" landing
+// page, in the same dotted-path form accepted by -metric and /api/stats.
+var dashboardMetrics = []string{
+	"IncrementalBuildTime",
+	"BuildTime",
+	"FileSizes.GAPID",
+	"FileSizes.GAPIS",
+	"FileSizes.GAPIR",
+	"CaptureStats.Frames",
+	"CaptureStats.DrawCalls",
+	"CaptureStats.Commands",
+}
+
+// runServe is the entry point for the "regress serve" subcommand: it reads
+// the -db history and exposes it as an HTML dashboard plus a JSON API.
+func runServe(ctx context.Context) error {
+	if *db == "" {
+		return fmt.Errorf("regress serve requires -db=path")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", serveDashboard)
+	mux.HandleFunc("/api/stats", serveAPIStats)
+
+	log.I(ctx, "Serving regression dashboard for %v on %v", *db, *addr)
+	return http.ListenAndServe(*addr, mux)
+}
+
+type seriesPoint struct {
+	SHA       string
+	Timestamp time.Time
+	Value     float64
+}
+
+// series extracts the named metric's mean from every record that has it, in
+// the order they were recorded. Records missing the metric are skipped
+// rather than failing the whole series, since a -db naturally accumulates
+// heterogeneous rows (different -pkg or -collectors between runs).
+func series(records []record, metric string) []seriesPoint {
+	points := make([]seriesPoint, 0, len(records))
+	for _, r := range records {
+		v, err := metricValue(r.Stats, metric)
+		if err != nil {
+			continue
+		}
+		points = append(points, seriesPoint{SHA: r.SHA, Timestamp: r.Timestamp, Value: v})
+	}
+	return points
+}
+
+func serveDashboard(w http.ResponseWriter, req *http.Request) {
+	records, err := loadRecords(*db)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, "<html><head><title>regress</title></head><body>\n")
+	fmt.Fprintf(w, "<h1>Regression history (%d changelists)</h1>\n", len(records))
+	for _, metric := range dashboardMetrics {
+		points := series(records, metric)
+		if len(points) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "<h2>%v</h2>\n", html.EscapeString(metric))
+		fmt.Fprint(w, sparkline(points))
+	}
+	fmt.Fprint(w, "</body></html>\n")
+}
+
+// sparkline renders points as a minimal inline SVG polyline, so the
+// dashboard needs no JS or charting dependency.
+func sparkline(points []seriesPoint) string {
+	const width, height = 600, 80
+	if len(points) == 0 {
+		return "<p>no data</p>"
+	}
+
+	min, max := points[0].Value, points[0].Value
+	for _, p := range points {
+		if p.Value < min {
+			min = p.Value
+		}
+		if p.Value > max {
+			max = p.Value
+		}
+	}
+	if max == min {
+		max = min + 1
+	}
+
+	coords := make([]string, len(points))
+	for i, p := range points {
+		x := float64(i) / float64(len(points)-1) * width
+		if len(points) == 1 {
+			x = 0
+		}
+		y := height - (p.Value-min)/(max-min)*height
+		coords[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+
+	return fmt.Sprintf(
+		`<svg width="%d" height="%d" style="border:1px solid #ccc"><polyline fill="none" stroke="steelblue" stroke-width="2" points="%v"/></svg>`,
+		width, height, strings.Join(coords, " "))
+}
+
+// serveAPIStats implements GET /api/stats?metric=...&since=..., returning a
+// JSON array of {SHA, Timestamp, Value} for consumption by external tools.
+func serveAPIStats(w http.ResponseWriter, req *http.Request) {
+	metricName := req.URL.Query().Get("metric")
+	if metricName == "" {
+		http.Error(w, "missing required 'metric' query parameter", http.StatusBadRequest)
+		return
+	}
+
+	var since time.Time
+	if s := req.URL.Query().Get("since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid 'since', want RFC3339: %v", err), http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+
+	records, err := loadRecords(*db)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filtered := records[:0:0]
+	for _, r := range records {
+		if r.Timestamp.Before(since) {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+
+	points := series(filtered, metricName)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(points)
+}
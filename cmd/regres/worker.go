@@ -0,0 +1,210 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/gapid/core/git"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/shell"
+)
+
+// measureRequest is what the coordinator POSTs to a 'regress worker' to ask
+// it to measure a single changelist. It carries every flag that affects
+// measure()'s output, so a worker reproduces exactly what the coordinator
+// would have done locally, rather than falling back to its own process-local
+// defaults.
+type measureRequest struct {
+	SHA     string
+	Subject string
+
+	Runs       int
+	Collectors string
+	Pkg        string
+	Optimize   bool
+}
+
+// measureResponse is a worker's reply: either Stats, or a non-empty Error.
+type measureResponse struct {
+	Stats stats
+	Error string `json:",omitempty"`
+}
+
+// measureDistributed hands cls out to the -workers over a shared, work-
+// stealing job queue: every worker goroutine pulls from the same channel,
+// so a faster host simply drains more of the queue rather than sitting
+// idle waiting for a statically assigned share. Results arrive in
+// completion order, since workers race each other to drain the queue, so
+// they're sorted back into the same oldest-to-newest order the single-host
+// path produces before being returned.
+func measureDistributed(ctx context.Context, cls []git.CL) ([]stats, error) {
+	addrs := strings.Split(*workers, ",")
+
+	order := make(map[string]int, len(cls))
+	jobs := make(chan git.CL, len(cls))
+	for i := range cls {
+		cl := cls[len(cls)-1-i] // feed oldest first; fast workers still steal ahead
+		order[cl.SHA.String()[:6]] = i
+		jobs <- cl
+	}
+	close(jobs)
+
+	results := make(chan stats, len(cls))
+
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			for cl := range jobs {
+				log.I(ctx, "Dispatching %v to %v: %v", cl.SHA.String()[:6], addr, cl.Subject)
+				r, err := callWorker(ctx, addr, cl)
+				if err != nil {
+					log.W(ctx, "Worker %v failed on %v: %v", addr, cl.SHA.String()[:6], err)
+					continue
+				}
+				results <- r
+			}
+		}(addr)
+	}
+
+	wg.Wait()
+	close(results)
+
+	res := make([]stats, 0, len(cls))
+	for r := range results {
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool { return order[res[i].SHA] < order[res[j].SHA] })
+	return res, nil
+}
+
+// callWorker asks the worker at addr to measure cl, over a plain HTTP+JSON
+// RPC (the existing 'regress serve' dashboard already follows this pattern,
+// and it needs no proto toolchain to stand up a small build farm). The
+// coordinator's own measurement flags travel along in the request, so the
+// worker reproduces them rather than using its own process-local defaults.
+func callWorker(ctx context.Context, addr string, cl git.CL) (stats, error) {
+	req := measureRequest{
+		SHA:        cl.SHA.String(),
+		Subject:    cl.Subject,
+		Runs:       *runs,
+		Collectors: *collectors,
+		Pkg:        *pkg,
+		Optimize:   *optimize,
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return stats{}, err
+	}
+
+	httpReq, err := http.NewRequest("POST", "http://"+addr+"/measure", bytes.NewReader(body))
+	if err != nil {
+		return stats{}, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return stats{}, err
+	}
+	defer resp.Body.Close()
+
+	var mr measureResponse
+	if err := json.NewDecoder(resp.Body).Decode(&mr); err != nil {
+		return stats{}, err
+	}
+	if mr.Error != "" {
+		return stats{}, fmt.Errorf("%v", mr.Error)
+	}
+	return mr.Stats, nil
+}
+
+// runWorker is the entry point for the "regress worker" subcommand: it
+// checks out the requested SHA in its own -root clone and runs the normal
+// measure() pipeline against it, so it shares all collector logic with the
+// single-host path.
+func runWorker(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/measure", handleMeasure)
+
+	log.I(ctx, "Worker listening on %v, building in %v", *addr, *root)
+	return http.ListenAndServe(*addr, mux)
+}
+
+// measureMu serializes requests handled by a single worker: handleMeasure
+// checks out a SHA into the shared -root clone and points the package-level
+// flag vars at the coordinator's requested config before calling measure(),
+// neither of which is safe to do for two requests at once.
+var measureMu sync.Mutex
+
+func handleMeasure(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var mreq measureRequest
+	if err := json.NewDecoder(req.Body).Decode(&mreq); err != nil {
+		writeMeasureError(w, err)
+		return
+	}
+
+	measureMu.Lock()
+	defer measureMu.Unlock()
+
+	checkout := shell.Cmd{Name: "git", Args: []string{"checkout", mreq.SHA}, Dir: *root, Verbosity: *verbose}
+	if _, err := checkout.Call(ctx); err != nil {
+		writeMeasureError(w, fmt.Errorf("checkout of %v failed: %v", mreq.SHA, err))
+		return
+	}
+
+	// Apply the coordinator's measurement config so this worker reproduces
+	// what a local, single-host run would have done for the same invocation.
+	if mreq.Runs > 0 {
+		*runs = mreq.Runs
+	}
+	if mreq.Collectors != "" {
+		*collectors = mreq.Collectors
+	}
+	*pkg = mreq.Pkg
+	*optimize = mreq.Optimize
+
+	sha := mreq.SHA
+	if len(sha) > 6 {
+		sha = sha[:6]
+	}
+	r, err := measure(ctx, sha, 0, mreq.Subject)
+	if err != nil {
+		writeMeasureError(w, err)
+		return
+	}
+	r.Host = hostFingerprint()
+
+	json.NewEncoder(w).Encode(measureResponse{Stats: r})
+}
+
+func writeMeasureError(w http.ResponseWriter, err error) {
+	json.NewEncoder(w).Encode(measureResponse{Error: err.Error()})
+}